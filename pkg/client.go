@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// newLoadingRules returns clientcmd's default loading rules (KUBECONFIG env var,
+// then ~/.kube/config) pinned to kubeConfig when it's explicitly set.
+func newLoadingRules(kubeConfig string) *clientcmd.ClientConfigLoadingRules {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeConfig
+	return rules
+}
+
+// NewKubernetesClientForContext builds a KubernetesClient for contextName out of
+// kubeconfig, so a single binary can exec across multiple clusters/contexts
+// loaded from one merged kubeconfig.
+func NewKubernetesClientForContext(kubeconfig, contextName string) (*KubernetesClient, error) {
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		newLoadingRules(kubeconfig),
+		&clientcmd.ConfigOverrides{
+			CurrentContext: contextName,
+		},
+	)
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	restConfig.APIPath = "/apis"
+	restConfig.UserAgent = rest.DefaultKubernetesUserAgent()
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KubernetesClient{
+		clientset:        clientset,
+		restConfig:       restConfig,
+		kubernetesConfig: kubeconfig,
+	}, nil
+}
+
+type clientCacheKey struct {
+	kubeconfig string
+	context    string
+}
+
+// ClientCache reuses KubernetesClients across repeat calls for the same
+// (kubeconfig path, context) pair, avoiding redundant config loads and
+// clientsets.
+type ClientCache struct {
+	mu      sync.Mutex
+	clients map[clientCacheKey]*KubernetesClient
+}
+
+// NewClientCache returns an empty ClientCache.
+func NewClientCache() *ClientCache {
+	return &ClientCache{clients: make(map[clientCacheKey]*KubernetesClient)}
+}
+
+// Get returns the cached KubernetesClient for (kubeconfig, context), creating
+// one via NewKubernetesClientForContext and caching it on first use.
+func (cc *ClientCache) Get(kubeconfig, context string) (*KubernetesClient, error) {
+	key := clientCacheKey{kubeconfig: kubeconfig, context: context}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if client, ok := cc.clients[key]; ok {
+		return client, nil
+	}
+
+	client, err := NewKubernetesClientForContext(kubeconfig, context)
+	if err != nil {
+		return nil, err
+	}
+
+	cc.clients[key] = client
+	return client, nil
+}
@@ -0,0 +1,238 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// CopyToPod uploads localPath into remotePath inside container, implementing
+// `kubectl cp` upload semantics: a tar archive of localPath is streamed into the
+// stdin of `tar -xmf - -C <dir>` running inside the pod.
+func (c *KubernetesClient) CopyToPod(namespace, pod, container, localPath, remotePath string) error {
+	destDir, destName := copyToPodDestination(localPath, remotePath)
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeTar(writer, localPath, destName))
+	}()
+
+	var stderr bytes.Buffer
+	code, err := c.ExecuteInteractive(&InteractiveOption{
+		NameSpace: namespace,
+		PodName:   pod,
+		Container: container,
+		Commands:  []string{"tar", "-xmf", "-", "-C", destDir},
+		Stdin:     reader,
+		Stderr:    &stderr,
+	})
+	if err != nil || code != 0 {
+		return copyError("copy to pod", pod, container, stderr.String(), code, err)
+	}
+
+	return nil
+}
+
+// CopyFromPod downloads remotePath out of container into localPath, implementing
+// `kubectl cp` download semantics: `tar -cf - -C <parent-dir> <base>` is run
+// inside the pod (so tar member names are relative to remotePath's basename,
+// not its full path) and its stdout is untarred locally with that basename
+// stripped back off, landing the content at localPath.
+func (c *KubernetesClient) CopyFromPod(namespace, pod, container, remotePath, localPath string) error {
+	parentDir := path.Dir(remotePath)
+	baseName := path.Base(remotePath)
+
+	reader, writer := io.Pipe()
+
+	var stderr bytes.Buffer
+	var code int
+	var execErr error
+	go func() {
+		code, execErr = c.ExecuteInteractive(&InteractiveOption{
+			NameSpace: namespace,
+			PodName:   pod,
+			Container: container,
+			Commands:  []string{"tar", "-cf", "-", "-C", parentDir, baseName},
+			Stdout:    writer,
+			Stderr:    &stderr,
+		})
+		writer.Close()
+	}()
+
+	if err := extractTar(reader, localPath, baseName); err != nil {
+		// Unblock the writer goroutine in case the remote tar stream still has
+		// unread data buffered in the pipe.
+		reader.CloseWithError(err)
+		return err
+	}
+
+	if execErr != nil || code != 0 {
+		return copyError("copy from pod", pod, container, stderr.String(), code, execErr)
+	}
+
+	return nil
+}
+
+// copyToPodDestination splits remotePath into the directory `tar -x` should
+// extract into and the name localPath's contents should be archived under. A
+// remotePath ending in "/" names a destination directory, so the upload keeps
+// localPath's own name underneath it; otherwise remotePath names the file/dir
+// itself, so its basename becomes the tar entry's name.
+func copyToPodDestination(localPath, remotePath string) (destDir, destName string) {
+	if strings.HasSuffix(remotePath, "/") {
+		return strings.TrimSuffix(remotePath, "/"), path.Base(localPath)
+	}
+	return path.Dir(remotePath), path.Base(remotePath)
+}
+
+func copyError(op, pod, container, stderr string, code int, err error) error {
+	if strings.Contains(stderr, "not found") || strings.Contains(stderr, "executable file not found") {
+		return fmt.Errorf("%s: tar not found in container %s/%s", op, pod, container)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return fmt.Errorf("%s: tar exited with code %d: %s", op, code, stderr)
+}
+
+// writeTar archives srcPath into w, naming entries as if srcPath were renamed to
+// destName, so untarring at the destination directory reproduces srcPath under
+// that name.
+func writeTar(w io.Writer, srcPath, destName string) error {
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	srcPath = filepath.Clean(srcPath)
+
+	return filepath.Walk(srcPath, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(srcPath, file)
+		if err != nil {
+			return err
+		}
+
+		name := destName
+		if rel != "." {
+			name = filepath.ToSlash(filepath.Join(destName, rel))
+		}
+
+		if info.IsDir() {
+			return tw.WriteHeader(&tar.Header{Name: name + "/", Mode: int64(info.Mode().Perm()), Typeflag: tar.TypeDir})
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// extractTar unpacks the tar stream read from r into destDir, stripping
+// rootName (the name of the remote source itself, e.g. the basename tar was
+// run with via `-C <parent-dir> <base>`) off the front of every member name so
+// that, for a single-file source, destDir ends up naming the file directly
+// rather than a directory containing it. Entries are rejected unless their
+// resolved path stays under destDir, and symlink/hardlink entries are rejected
+// outright, to guard against a malicious or compromised container smuggling a
+// "tar-slip" path (e.g. "../../.ssh/authorized_keys") through
+// `tar -cf - -C <parent-dir> <base>` (the class of bug fixed as CVE-2019-1002101 in
+// kubectl cp itself).
+func extractTar(r io.Reader, destDir, rootName string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink {
+			return fmt.Errorf("refusing to extract link entry %q from tar stream", hdr.Name)
+		}
+
+		rel, err := stripRoot(hdr.Name, rootName)
+		if err != nil {
+			return err
+		}
+
+		target, err := safeJoin(destDir, rel)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			f.Close()
+		}
+	}
+}
+
+// stripRoot removes the rootName prefix from a tar member name, returning the
+// path relative to it ("" for rootName itself). It rejects any name that isn't
+// rootName or a path underneath it, since that's not a shape `-C <dir> <root>`
+// should ever produce.
+func stripRoot(name, rootName string) (string, error) {
+	name = strings.TrimSuffix(name, "/")
+	switch {
+	case name == rootName:
+		return "", nil
+	case strings.HasPrefix(name, rootName+"/"):
+		return strings.TrimPrefix(name, rootName+"/"), nil
+	default:
+		return "", fmt.Errorf("tar entry %q is not rooted at expected name %q", name, rootName)
+	}
+}
+
+// safeJoin joins destDir and name, rejecting the result unless it stays at or
+// under destDir.
+func safeJoin(destDir, name string) (string, error) {
+	target := filepath.Join(destDir, name)
+
+	rel, err := filepath.Rel(destDir, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("tar entry %q escapes destination directory %q", name, destDir)
+	}
+
+	return target, nil
+}
@@ -0,0 +1,217 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyToPodDestination(t *testing.T) {
+	tests := []struct {
+		name       string
+		localPath  string
+		remotePath string
+		wantDir    string
+		wantName   string
+	}{
+		{
+			name:       "file destination keeps remote basename",
+			localPath:  "/home/me/app.log",
+			remotePath: "/var/log/renamed.log",
+			wantDir:    "/var/log",
+			wantName:   "renamed.log",
+		},
+		{
+			name:       "directory destination keeps local basename",
+			localPath:  "/home/me/app.log",
+			remotePath: "/var/log/",
+			wantDir:    "/var/log",
+			wantName:   "app.log",
+		},
+		{
+			name:       "directory upload into a directory destination",
+			localPath:  "/home/me/configs",
+			remotePath: "/etc/app/",
+			wantDir:    "/etc/app",
+			wantName:   "configs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destDir, destName := copyToPodDestination(tt.localPath, tt.remotePath)
+			if destDir != tt.wantDir || destName != tt.wantName {
+				t.Errorf("copyToPodDestination(%q, %q) = (%q, %q), want (%q, %q)",
+					tt.localPath, tt.remotePath, destDir, destName, tt.wantDir, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestWriteTarNamesEntriesUnderDestName(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "file.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatalf("seed source file: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeTar(&buf, srcDir, "renamed"); err != nil {
+		t.Fatalf("writeTar: %s", err)
+	}
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar entry: %s", err)
+		}
+		names = append(names, hdr.Name)
+	}
+
+	wantNames := map[string]bool{"renamed/": true, "renamed/file.txt": true}
+	if len(names) != len(wantNames) {
+		t.Fatalf("got entries %v, want entries %v", names, wantNames)
+	}
+	for _, n := range names {
+		if !wantNames[n] {
+			t.Errorf("unexpected tar entry name %q", n)
+		}
+	}
+}
+
+func TestExtractTarRejectsPathTraversal(t *testing.T) {
+	destDir := t.TempDir()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	body := []byte("pwned")
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "src/../../../../tmp/evil.txt",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(body)),
+	}); err != nil {
+		t.Fatalf("write header: %s", err)
+	}
+	if _, err := tw.Write(body); err != nil {
+		t.Fatalf("write body: %s", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar writer: %s", err)
+	}
+
+	if err := extractTar(&buf, destDir, "src"); err == nil {
+		t.Fatalf("expected extractTar to reject a path-traversal entry, got nil error")
+	}
+
+	if _, err := os.Stat(filepath.Join("/tmp", "evil.txt")); err == nil {
+		t.Fatalf("path-traversal entry escaped destDir and was written to /tmp/evil.txt")
+	}
+}
+
+func TestExtractTarRejectsSymlink(t *testing.T) {
+	destDir := t.TempDir()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "src/evil-link",
+		Typeflag: tar.TypeSymlink,
+		Linkname: "/etc/passwd",
+	}); err != nil {
+		t.Fatalf("write header: %s", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar writer: %s", err)
+	}
+
+	if err := extractTar(&buf, destDir, "src"); err == nil {
+		t.Fatalf("expected extractTar to reject a symlink entry, got nil error")
+	}
+
+	if _, err := os.Lstat(filepath.Join(destDir, "evil-link")); err == nil {
+		t.Fatalf("symlink entry was written to destDir")
+	}
+}
+
+// TestExtractTarStripsRootNameForFile is a regression test for a bug where a
+// single-file download landed nested under its own name (e.g.
+// "<localPath>/app.log") instead of at localPath itself, because the tar
+// stream's member name wasn't stripped before joining onto destDir.
+func TestExtractTarStripsRootNameForFile(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "out.log")
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	body := []byte("hello")
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "app.log",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(body)),
+	}); err != nil {
+		t.Fatalf("write header: %s", err)
+	}
+	if _, err := tw.Write(body); err != nil {
+		t.Fatalf("write body: %s", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar writer: %s", err)
+	}
+
+	if err := extractTar(&buf, target, "app.log"); err != nil {
+		t.Fatalf("extractTar: %s", err)
+	}
+
+	got, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("expected downloaded bytes at %s, got error: %s", target, err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("extracted content = %q, want %q", got, "hello")
+	}
+}
+
+func TestExtractTarStripsRootNameForDirectory(t *testing.T) {
+	destDir := t.TempDir()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "confs/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatalf("write dir header: %s", err)
+	}
+	body := []byte("value")
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "confs/app.conf",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(body)),
+	}); err != nil {
+		t.Fatalf("write file header: %s", err)
+	}
+	if _, err := tw.Write(body); err != nil {
+		t.Fatalf("write body: %s", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar writer: %s", err)
+	}
+
+	if err := extractTar(&buf, destDir, "confs"); err != nil {
+		t.Fatalf("extractTar: %s", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(destDir, "app.conf"))
+	if err != nil {
+		t.Fatalf("expected downloaded file directly under destDir, got error: %s", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("extracted content = %q, want %q", got, "value")
+	}
+}
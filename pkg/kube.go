@@ -1,11 +1,14 @@
 package pkg
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"os"
+	"sync"
+	"time"
 
 	api "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -14,6 +17,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
 	"k8s.io/klog"
 )
 
@@ -22,21 +26,23 @@ type KubernetesClient struct {
 
 	restConfig       *rest.Config
 	kubernetesConfig string
+
+	// podInformers caches a podInformer per namespace, lazily created by
+	// WaitForPodReady/podInformerFor and reused across calls for that
+	// namespace; podInformersMu guards the map against concurrent callers.
+	podInformersMu sync.Mutex
+	podInformers   map[string]*podInformer
 }
 
 func NewKubernetesClient(kubeconfig string) (*KubernetesClient, error) {
 	klog.Infof(kubeconfig)
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return nil, err
-	}
 
 	restConfig, err := NewRestConfig(kubeconfig)
 	if err != nil {
 		return nil, err
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	clientset, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -48,11 +54,22 @@ func NewKubernetesClient(kubeconfig string) (*KubernetesClient, error) {
 	}, nil
 }
 
+// NewRestConfig builds a *rest.Config for kubeConfig. When kubeConfig is empty
+// and KUBECONFIG is not set, it falls back to rest.InClusterConfig(), matching
+// clientcmd's default loading rules plus in-cluster detection.
 func NewRestConfig(kubeConfig string) (*rest.Config, error) {
+	if kubeConfig == "" && os.Getenv("KUBECONFIG") == "" {
+		if clusterConfig, err := rest.InClusterConfig(); err == nil {
+			clusterConfig.APIPath = "/apis"
+			clusterConfig.UserAgent = rest.DefaultKubernetesUserAgent()
+			return clusterConfig, nil
+		} else if err != rest.ErrNotInCluster {
+			klog.Warningf("in-cluster config detection failed: %s", err)
+		}
+	}
+
 	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{
-			ExplicitPath: kubeConfig,
-		},
+		newLoadingRules(kubeConfig),
 		&clientcmd.ConfigOverrides{},
 	)
 	clusterConfig, err := clientConfig.ClientConfig()
@@ -73,6 +90,12 @@ type Option struct {
 	PodName   string
 	Container string
 	Commands  []string
+
+	// WaitTimeout, when non-zero, makes ExecuteCommand wait for the target
+	// container to become ready via WaitForPodReady instead of relying on a
+	// single Get + phase check, so callers can exec into a pod that is still
+	// starting without racing it.
+	WaitTimeout time.Duration
 }
 
 type Response struct {
@@ -82,6 +105,137 @@ type Response struct {
 	Result  interface{} `json:"result,omitempty"`
 }
 
+// resolveContainer fetches opt.PodName, checks it isn't already finished, and
+// resolves the container to exec into: opt.Container if set (validated against
+// the pod spec), otherwise the pod's first container. It's shared by Execute and
+// ExecuteInteractive so both apply the same defaulting and validation rules.
+func (c *KubernetesClient) resolveContainer(ctx context.Context, namespace, podName, container string) (*api.Pod, string, error) {
+	pod, err := c.clientset.CoreV1().Pods(namespace).Get(ctx, podName, v1.GetOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed {
+		return nil, "", fmt.Errorf("cannot exec into a container in a completed pod; current phase is %s", pod.Status.Phase)
+	}
+
+	if len(container) == 0 {
+		if len(pod.Spec.Containers) > 1 {
+			klog.Warningf("Defaulting container name to %s.", pod.Spec.Containers[0].Name)
+		}
+		return pod, pod.Spec.Containers[0].Name, nil
+	}
+
+	for _, c := range pod.Spec.Containers {
+		if c.Name == container {
+			return pod, container, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("container name: %s not found in pod %s", container, podName)
+}
+
+// ExecResult holds the raw stdout/stderr produced by a command run via Execute,
+// along with its exit code, without assuming anything about the output format.
+type ExecResult struct {
+	Stdout   bytes.Buffer
+	Stderr   bytes.Buffer
+	ExitCode int
+}
+
+// Execute runs opt.Commands in opt.NameSpace/opt.PodName/opt.Container and
+// returns the raw stdout/stderr plus exit code. Unlike the deprecated
+// ExecuteCommand, it does not assume the command prints a Response-shaped JSON
+// blob; use ExecuteCommandJSON when that assumption does hold.
+func (c *KubernetesClient) Execute(opt *Option) (*ExecResult, error) {
+	klog.Infof("Start to execute command :%s", opt)
+	if len(opt.PodName) == 0 {
+		return nil, fmt.Errorf("can not execute command with empty pod name")
+	}
+
+	if len(opt.NameSpace) == 0 {
+		opt.NameSpace = "default"
+	}
+
+	if opt.WaitTimeout > 0 {
+		if err := c.WaitForPodReady(context.TODO(), opt.NameSpace, opt.PodName, opt.Container, opt.WaitTimeout); err != nil {
+			return nil, fmt.Errorf("wait for pod ready for %s got error : %s", opt, err)
+		}
+	}
+
+	_, container, err := c.resolveContainer(context.TODO(), opt.NameSpace, opt.PodName, opt.Container)
+	if err != nil {
+		klog.Errorf("resolve container for %s got error : %s", opt, err)
+		return nil, err
+	}
+
+	restClient := c.clientset.CoreV1().RESTClient()
+	req := restClient.Post().
+		Resource("pods").
+		Name(opt.PodName).
+		Namespace(opt.NameSpace).
+		SubResource("exec")
+
+	req.VersionedParams(&api.PodExecOptions{
+		Container: container,
+		Command:   opt.Commands,
+		Stdin:     false,
+		Stdout:    true,
+		Stderr:    true,
+		TTY:       false,
+	}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(c.restConfig, "POST", req.URL())
+	if err != nil {
+		klog.Errorf("error when NewSPDYExecutor, err: %s", err)
+		return nil, fmt.Errorf("error when NewSPDYExecutor, err: %s", err)
+	}
+
+	result := &ExecResult{}
+	err = exec.Stream(remotecommand.StreamOptions{
+		Stdout: &result.Stdout,
+		Stderr: &result.Stderr,
+		Tty:    false,
+	})
+	if err != nil {
+		exitErr, ok := err.(utilexec.CodeExitError)
+		if !ok {
+			klog.Errorf("error when stream, err: %s", err)
+			return nil, err
+		}
+		result.ExitCode = exitErr.Code
+	}
+
+	return result, nil
+}
+
+// ExecuteCommandJSON runs opt.Commands and decodes its stdout as JSON into a
+// value of type T. It returns an error if the command exited non-zero or its
+// stdout isn't valid JSON for T.
+func ExecuteCommandJSON[T any](c *KubernetesClient, opt *Option) (*T, error) {
+	result, err := c.Execute(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("command exited with code %d: %s", result.ExitCode, result.Stderr.String())
+	}
+
+	var out T
+	if err := json.Unmarshal(result.Stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("unmarsh json %s got error: %s", result.Stdout.String(), err)
+	}
+
+	return &out, nil
+}
+
+// ExecuteCommand runs opt.Commands and unmarshals its stdout directly into a
+// Response, returning Code: 1 when isDestroy is set and the pod is already gone.
+//
+// Deprecated: this assumes the command's stdout is a Response-shaped JSON blob,
+// which breaks for any command that isn't. Use Execute or ExecuteCommandJSON
+// instead.
 func (c *KubernetesClient) ExecuteCommand(opt *Option, isDestroy bool) *Response {
 	klog.Infof("Start to execute command :%s", opt)
 	if len(opt.PodName) == 0 {
@@ -95,9 +249,8 @@ func (c *KubernetesClient) ExecuteCommand(opt *Option, isDestroy bool) *Response
 		opt.NameSpace = "default"
 	}
 
-	pod, err := c.clientset.CoreV1().Pods(opt.NameSpace).Get(context.TODO(), opt.PodName, v1.GetOptions{})
+	resp, err := ExecuteCommandJSON[Response](c, opt)
 	if err != nil {
-		klog.Errorf("get pod for %s got error : %s", opt, err)
 		notFoundInfo := fmt.Sprintf("pods \"%s\" not found", opt.PodName)
 		if isDestroy && err.Error() == notFoundInfo {
 			return &Response{
@@ -105,36 +258,52 @@ func (c *KubernetesClient) ExecuteCommand(opt *Option, isDestroy bool) *Response
 			}
 		}
 
+		klog.Errorf("get pod for %s got error : %s", opt, err)
 		return &Response{
 			Code: -1, Success: false, Err: err.Error(),
 		}
 	}
 
-	if pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed {
-		return &Response{
-			Code: -1, Success: false, Err: fmt.Sprintf("cannot exec into a container in a completed pod; current phase is %s", pod.Status.Phase),
-		}
+	return resp
+}
+
+// InteractiveOption describes an interactive exec session: the pod/container to
+// attach to, the command to run, and the streams used for bidirectional I/O. Stdin,
+// Stdout and Stderr are optional; set TTY to allocate a pty on the remote side and
+// TerminalSizeQueue to push remotecommand.TerminalSize events when the local
+// terminal is resized.
+type InteractiveOption struct {
+	NameSpace string
+	PodName   string
+	Container string
+	Commands  []string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	TTY    bool
+
+	TerminalSizeQueue remotecommand.TerminalSizeQueue
+}
+
+// ExecuteInteractive runs opt.Commands in opt.PodName/opt.Container and streams
+// opt.Stdin/opt.Stdout/opt.Stderr for the lifetime of the session, suitable for a
+// `kubectl exec -it` style replacement. It returns the exit code reported by the
+// executed command.
+func (c *KubernetesClient) ExecuteInteractive(opt *InteractiveOption) (int, error) {
+	klog.Infof("Start to execute interactive command :%s", opt)
+	if len(opt.PodName) == 0 {
+		return -1, fmt.Errorf("can not execute command with empty pod name")
 	}
 
-	if len(opt.Container) == 0 {
-		if len(pod.Spec.Containers) > 1 {
-			klog.Warningf("Defaulting container name to %s.", pod.Spec.Containers[0].Name)
-		}
-		opt.Container = pod.Spec.Containers[0].Name
-	} else {
-		matched := false
-		for _, c := range pod.Spec.Containers {
-			if c.Name == opt.Container {
-				matched = true
-				break
-			}
-		}
+	if len(opt.NameSpace) == 0 {
+		opt.NameSpace = "default"
+	}
 
-		if !matched {
-			return &Response{
-				Code: -1, Success: false, Err: fmt.Sprintf("container name: %s not found in pod %s", opt.Container, opt.PodName),
-			}
-		}
+	_, container, err := c.resolveContainer(context.TODO(), opt.NameSpace, opt.PodName, opt.Container)
+	if err != nil {
+		klog.Errorf("resolve container for %s got error : %s", opt, err)
+		return -1, err
 	}
 
 	restClient := c.clientset.CoreV1().RESTClient()
@@ -145,50 +314,36 @@ func (c *KubernetesClient) ExecuteCommand(opt *Option, isDestroy bool) *Response
 		SubResource("exec")
 
 	req.VersionedParams(&api.PodExecOptions{
-		Container: opt.Container,
+		Container: container,
 		Command:   opt.Commands,
-		Stdin:     false,
-		Stdout:    true,
-		Stderr:    true,
-		TTY:       false,
+		Stdin:     opt.Stdin != nil,
+		Stdout:    opt.Stdout != nil,
+		// A TTY multiplexes stdout/stderr onto a single stream, so the API server
+		// rejects a request that asks for both TTY and a separate stderr stream.
+		Stderr: opt.Stderr != nil && !opt.TTY,
+		TTY:    opt.TTY,
 	}, scheme.ParameterCodec)
 
 	exec, err := remotecommand.NewSPDYExecutor(c.restConfig, "POST", req.URL())
 	if err != nil {
 		klog.Errorf("error when NewSPDYExecutor, err: %s", err)
-		return &Response{
-			Code: -1, Success: false, Err: fmt.Sprintf("error when NewSPDYExecutor, err: %s", err),
-		}
+		return -1, fmt.Errorf("error when NewSPDYExecutor, err: %s", err)
 	}
 
-	reader, writer := io.Pipe()
-	go func() {
-		defer writer.Close()
-		err = exec.Stream(remotecommand.StreamOptions{
-			Stdout: writer,
-			Stderr: writer,
-			Tty:    false,
-		})
-	}()
-
-	buffer, err := ioutil.ReadAll(reader)
+	err = exec.Stream(remotecommand.StreamOptions{
+		Stdin:             opt.Stdin,
+		Stdout:            opt.Stdout,
+		Stderr:            opt.Stderr,
+		Tty:               opt.TTY,
+		TerminalSizeQueue: opt.TerminalSizeQueue,
+	})
 	if err != nil {
-		klog.Warningf("read resp got error: %s", err)
-		return &Response{
-			Code: -1, Success: false, Err: fmt.Sprintf("read resp got error: %s", err),
-		}
-	}
-
-	respString := string(buffer)
-	klog.Infof("exec result for :%s: ret: %s", opt, respString)
-	var resp Response
-	err = json.Unmarshal(buffer, &resp)
-	if err != nil {
-		klog.Warningf("unmarsh json %s got error: %s", respString, err)
-		return &Response{
-			Code: -1, Success: false, Err: fmt.Sprintf("unmarsh json %s got error: %s", respString, err),
+		if exitErr, ok := err.(utilexec.CodeExitError); ok {
+			return exitErr.Code, exitErr
 		}
+		klog.Errorf("error when stream, err: %s", err)
+		return -1, err
 	}
 
-	return &resp
+	return 0, nil
 }
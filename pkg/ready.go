@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+const podReadyPollInterval = time.Second
+
+// podInformer is a pod informer/lister scoped to a single namespace, created
+// once per namespace by podInformerFor and reused across WaitForPodReady calls
+// for that namespace.
+type podInformer struct {
+	informer cache.SharedIndexInformer
+	lister   corelisters.PodLister
+	synced   cache.InformerSynced
+	stopCh   chan struct{}
+}
+
+// newPodInformer builds a pod informer/lister scoped to namespace, so waiting
+// on a single pod's readiness only requires list/watch RBAC and list traffic
+// for that namespace rather than the whole cluster.
+func newPodInformer(clientset kubernetes.Interface, namespace string) *podInformer {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 30*time.Second, informers.WithNamespace(namespace))
+	pods := factory.Core().V1().Pods()
+
+	pi := &podInformer{
+		informer: pods.Informer(),
+		lister:   pods.Lister(),
+		synced:   pods.Informer().HasSynced,
+		stopCh:   make(chan struct{}),
+	}
+
+	factory.Start(pi.stopCh)
+	return pi
+}
+
+// podInformerFor returns the cached namespace-scoped podInformer for
+// namespace, creating and caching one on first use. Callers across goroutines
+// share the same informer for a given namespace.
+func (c *KubernetesClient) podInformerFor(namespace string) *podInformer {
+	c.podInformersMu.Lock()
+	defer c.podInformersMu.Unlock()
+
+	if pi, ok := c.podInformers[namespace]; ok {
+		return pi
+	}
+
+	pi := newPodInformer(c.clientset, namespace)
+	if c.podInformers == nil {
+		c.podInformers = make(map[string]*podInformer)
+	}
+	c.podInformers[namespace] = pi
+	return pi
+}
+
+// WaitForPodReady blocks until the named container in namespace/pod reports
+// Running with Ready=true. If ctx is done or timeout elapses first, it returns an
+// error carrying the last reason the container wasn't ready (ImagePullBackOff,
+// CrashLoopBackOff, ContainerCreating, ...). If container is empty, it waits for
+// every container in the pod.
+// The underlying informer is scoped to namespace and created once per
+// namespace, shared across calls for that namespace.
+func (c *KubernetesClient) WaitForPodReady(ctx context.Context, namespace, podName, container string, timeout time.Duration) error {
+	pi := c.podInformerFor(namespace)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(ctx.Done(), pi.synced) {
+		return fmt.Errorf("failed to sync pod informer cache")
+	}
+
+	lastReason := "pod not found"
+	for {
+		pod, err := pi.lister.Pods(namespace).Get(podName)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		if pod != nil {
+			ready, reason := podContainerReady(pod, container)
+			if ready {
+				return nil
+			}
+			if reason != "" {
+				klog.Infof("waiting for %s/%s container %q: %s", namespace, podName, container, reason)
+				lastReason = reason
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for pod %s/%s container %q to become ready: %s", namespace, podName, container, lastReason)
+		case <-time.After(podReadyPollInterval):
+		}
+	}
+}
+
+// podContainerReady reports whether container (or, if empty, every container) in
+// pod is Running and Ready, along with a human-readable reason when it isn't.
+func podContainerReady(pod *api.Pod, container string) (bool, string) {
+	if pod.Status.Phase == api.PodFailed {
+		return false, fmt.Sprintf("pod is in phase %s", pod.Status.Phase)
+	}
+
+	found := false
+	for _, cs := range pod.Status.ContainerStatuses {
+		if container != "" && cs.Name != container {
+			continue
+		}
+		found = true
+
+		if cs.State.Waiting != nil {
+			return false, cs.State.Waiting.Reason
+		}
+		if cs.State.Terminated != nil {
+			return false, fmt.Sprintf("container terminated: %s", cs.State.Terminated.Reason)
+		}
+		if !cs.Ready || cs.State.Running == nil {
+			return false, "ContainerCreating"
+		}
+	}
+
+	if !found {
+		return false, "ContainerCreating"
+	}
+
+	return true, ""
+}
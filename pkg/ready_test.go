@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+)
+
+func TestPodContainerReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		pod        *api.Pod
+		container  string
+		wantReady  bool
+		wantReason string
+	}{
+		{
+			name: "running and ready single container",
+			pod: &api.Pod{
+				Status: api.PodStatus{
+					Phase: api.PodRunning,
+					ContainerStatuses: []api.ContainerStatus{
+						{Name: "app", Ready: true, State: api.ContainerState{Running: &api.ContainerStateRunning{}}},
+					},
+				},
+			},
+			wantReady: true,
+		},
+		{
+			name: "named container not yet reported",
+			pod: &api.Pod{
+				Status: api.PodStatus{
+					Phase: api.PodPending,
+				},
+			},
+			container:  "app",
+			wantReady:  false,
+			wantReason: "ContainerCreating",
+		},
+		{
+			name: "waiting on image pull",
+			pod: &api.Pod{
+				Status: api.PodStatus{
+					Phase: api.PodPending,
+					ContainerStatuses: []api.ContainerStatus{
+						{Name: "app", State: api.ContainerState{Waiting: &api.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+					},
+				},
+			},
+			wantReady:  false,
+			wantReason: "ImagePullBackOff",
+		},
+		{
+			name: "crash loop",
+			pod: &api.Pod{
+				Status: api.PodStatus{
+					Phase: api.PodRunning,
+					ContainerStatuses: []api.ContainerStatus{
+						{Name: "app", State: api.ContainerState{Waiting: &api.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+					},
+				},
+			},
+			wantReady:  false,
+			wantReason: "CrashLoopBackOff",
+		},
+		{
+			name: "terminated",
+			pod: &api.Pod{
+				Status: api.PodStatus{
+					Phase: api.PodRunning,
+					ContainerStatuses: []api.ContainerStatus{
+						{Name: "app", State: api.ContainerState{Terminated: &api.ContainerStateTerminated{Reason: "Error"}}},
+					},
+				},
+			},
+			wantReady:  false,
+			wantReason: "container terminated: Error",
+		},
+		{
+			name: "running but not ready yet",
+			pod: &api.Pod{
+				Status: api.PodStatus{
+					Phase: api.PodRunning,
+					ContainerStatuses: []api.ContainerStatus{
+						{Name: "app", Ready: false, State: api.ContainerState{Running: &api.ContainerStateRunning{}}},
+					},
+				},
+			},
+			wantReady:  false,
+			wantReason: "ContainerCreating",
+		},
+		{
+			name: "pod failed",
+			pod: &api.Pod{
+				Status: api.PodStatus{
+					Phase: api.PodFailed,
+				},
+			},
+			wantReady:  false,
+			wantReason: "pod is in phase Failed",
+		},
+		{
+			name: "empty container name waits for every container",
+			pod: &api.Pod{
+				Status: api.PodStatus{
+					Phase: api.PodRunning,
+					ContainerStatuses: []api.ContainerStatus{
+						{Name: "app", Ready: true, State: api.ContainerState{Running: &api.ContainerStateRunning{}}},
+						{Name: "sidecar", Ready: false, State: api.ContainerState{Running: &api.ContainerStateRunning{}}},
+					},
+				},
+			},
+			wantReady:  false,
+			wantReason: "ContainerCreating",
+		},
+		{
+			name: "empty container name, all containers ready",
+			pod: &api.Pod{
+				Status: api.PodStatus{
+					Phase: api.PodRunning,
+					ContainerStatuses: []api.ContainerStatus{
+						{Name: "app", Ready: true, State: api.ContainerState{Running: &api.ContainerStateRunning{}}},
+						{Name: "sidecar", Ready: true, State: api.ContainerState{Running: &api.ContainerStateRunning{}}},
+					},
+				},
+			},
+			wantReady: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, reason := podContainerReady(tt.pod, tt.container)
+			if ready != tt.wantReady {
+				t.Errorf("ready = %v, want %v (reason %q)", ready, tt.wantReady, reason)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Package wsexec bridges browser terminal frontends (e.g. xterm.js) to pod exec
+// sessions over WebSocket, so callers don't need to implement their own SPDY
+// plumbing to embed a web shell.
+package wsexec
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/klog"
+
+	"github.com/gorilla/websocket"
+	"github.com/wccsama/kubectl-exec-go/pkg"
+)
+
+// Channel-prefixed binary framing shared with xterm.js/kubectl web terminals:
+// the first byte of every WebSocket message identifies the stream it belongs to.
+const (
+	channelStdin = iota
+	channelStdout
+	channelStderr
+	channelError
+	channelResize
+)
+
+var upgrader = websocket.Upgrader{
+	// Embedding consumers are expected to authorize the request before it reaches
+	// this handler, so origin checking is left to them.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type resizeMessage struct {
+	Width  uint16
+	Height uint16
+}
+
+// Handler upgrades incoming WebSocket connections and proxies them to a pod's
+// exec subresource using Client.
+type Handler struct {
+	Client *pkg.KubernetesClient
+}
+
+// NewHandler returns an http.Handler that bridges browser terminals to pod exec
+// sessions via client.
+func NewHandler(client *pkg.KubernetesClient) *Handler {
+	return &Handler{Client: client}
+}
+
+// ServeHTTP reads namespace/pod/container/command/tty from the request's query
+// parameters, upgrades the connection to a WebSocket, and streams an exec session
+// over it until either side closes the connection.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	namespace := q.Get("namespace")
+	podName := q.Get("pod")
+	container := q.Get("container")
+	tty := q.Get("tty") != "false"
+
+	if podName == "" {
+		http.Error(w, "pod is required", http.StatusBadRequest)
+		return
+	}
+
+	commands := strings.Fields(q.Get("command"))
+	if len(commands) == 0 {
+		commands = []string{"/bin/sh"}
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		klog.Errorf("wsexec: upgrade failed: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	out := &frameConn{conn: conn}
+
+	stdinReader, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	sizeQueue := newChanSizeQueue()
+	defer sizeQueue.close()
+
+	go readLoop(conn, stdinWriter, sizeQueue)
+
+	code, err := h.Client.ExecuteInteractive(&pkg.InteractiveOption{
+		NameSpace:         namespace,
+		PodName:           podName,
+		Container:         container,
+		Commands:          commands,
+		Stdin:             stdinReader,
+		Stdout:            out.writerFor(channelStdout),
+		Stderr:            out.writerFor(channelStderr),
+		TTY:               tty,
+		TerminalSizeQueue: sizeQueue,
+	})
+	if err != nil {
+		klog.Warningf("wsexec: exec for %s/%s failed: %s", namespace, podName, err)
+		out.writeFrame(channelError, []byte(err.Error()))
+		return
+	}
+
+	klog.Infof("wsexec: exec for %s/%s exited with code %d", namespace, podName, code)
+}
+
+// readLoop pumps incoming WebSocket frames into stdin (channelStdin) or the
+// resize queue (channelResize) until the connection closes.
+func readLoop(conn *websocket.Conn, stdin io.WriteCloser, sizeQueue *chanSizeQueue) {
+	defer stdin.Close()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		channel, payload := data[0], data[1:]
+		switch channel {
+		case channelStdin:
+			if _, err := stdin.Write(payload); err != nil {
+				return
+			}
+		case channelResize:
+			var msg resizeMessage
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				klog.Warningf("wsexec: bad resize frame: %s", err)
+				continue
+			}
+			sizeQueue.push(remotecommand.TerminalSize{Width: msg.Width, Height: msg.Height})
+		}
+	}
+}
+
+// frameConn serializes writes of channel-tagged frames to the underlying
+// WebSocket connection, which gorilla/websocket does not allow concurrently.
+type frameConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (f *frameConn) writeFrame(channel byte, p []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conn.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, p...))
+}
+
+func (f *frameConn) writerFor(channel byte) io.Writer {
+	return &channelWriter{conn: f, channel: channel}
+}
+
+type channelWriter struct {
+	conn    *frameConn
+	channel byte
+}
+
+func (w *channelWriter) Write(p []byte) (int, error) {
+	if err := w.conn.writeFrame(w.channel, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// chanSizeQueue adapts a channel of TerminalSize events to the
+// remotecommand.TerminalSizeQueue interface expected by the exec stream. mu
+// guards against push() sending on q.ch concurrently with close() closing it.
+type chanSizeQueue struct {
+	mu     sync.Mutex
+	ch     chan remotecommand.TerminalSize
+	closed bool
+}
+
+func newChanSizeQueue() *chanSizeQueue {
+	return &chanSizeQueue{ch: make(chan remotecommand.TerminalSize)}
+}
+
+func (q *chanSizeQueue) push(size remotecommand.TerminalSize) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+
+	select {
+	case q.ch <- size:
+	default:
+		// Drop the event if the executor hasn't consumed the previous one yet;
+		// the next resize will supersede it anyway.
+	}
+}
+
+func (q *chanSizeQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+}
+
+// Next implements remotecommand.TerminalSizeQueue.
+func (q *chanSizeQueue) Next() *remotecommand.TerminalSize {
+	size, ok := <-q.ch
+	if !ok {
+		return nil
+	}
+	return &size
+}